@@ -0,0 +1,152 @@
+package client
+
+import "time"
+
+// EventKind is the kind of change described by an [Event].
+type EventKind int
+
+// Kinds of persistent-client change events.
+const (
+	EventAdded EventKind = iota + 1
+	EventUpdated
+	EventRemoved
+)
+
+// Event describes a change to a persistent client stored in a [Storage].
+// Old is nil for [EventAdded], and New is nil for [EventRemoved].
+type Event struct {
+	Old  *Persistent
+	New  *Persistent
+	Kind EventKind
+}
+
+// eventBufferSize is the number of events buffered for a subscriber before
+// the oldest one is dropped to make room for a new one.
+const eventBufferSize = 32
+
+// eventSub is a single subscriber's delivery pipeline.  Events are pushed
+// into queue without blocking the producer; a dedicated goroutine forwards
+// them to ch one at a time, so that one slow subscriber can't stall the
+// others or the producer.
+type eventSub struct {
+	ch    chan Event
+	queue chan Event
+	done  chan struct{}
+}
+
+// newEventSub returns a new eventSub and starts its forwarding goroutine.
+func newEventSub() (sub *eventSub) {
+	sub = &eventSub{
+		ch:    make(chan Event),
+		queue: make(chan Event, eventBufferSize),
+		done:  make(chan struct{}),
+	}
+
+	go sub.run()
+
+	return sub
+}
+
+// run forwards queued events to ch until the subscription is closed.
+func (sub *eventSub) run() {
+	for {
+		select {
+		case ev := <-sub.queue:
+			select {
+			case sub.ch <- ev:
+			case <-sub.done:
+				return
+			}
+		case <-sub.done:
+			return
+		}
+	}
+}
+
+// push enqueues ev for delivery without blocking, dropping the oldest queued
+// event first if the buffer is full.
+func (sub *eventSub) push(ev Event) {
+	select {
+	case sub.queue <- ev:
+		return
+	default:
+	}
+
+	select {
+	case <-sub.queue:
+	default:
+	}
+
+	select {
+	case sub.queue <- ev:
+	default:
+	}
+}
+
+// close stops the forwarding goroutine.
+func (sub *eventSub) close() {
+	close(sub.done)
+}
+
+// Subscribe registers a new subscriber and returns the channel that change
+// events will be delivered on.  The channel is never closed by Storage;
+// call [Storage.Unsubscribe] once the caller is done with it to release the
+// subscription.
+func (s *Storage) Subscribe() (events <-chan Event) {
+	sub := newEventSub()
+
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	s.subs[sub.ch] = sub
+
+	return sub.ch
+}
+
+// Unsubscribe stops delivering events on events, which must be a channel
+// previously returned by [Storage.Subscribe].  It's a no-op if events is
+// unknown, e.g. because it has already been unsubscribed.
+func (s *Storage) Unsubscribe(events <-chan Event) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	for ch, sub := range s.subs {
+		if ch == events {
+			sub.close()
+			delete(s.subs, ch)
+
+			return
+		}
+	}
+}
+
+// emit fans ev out to every current subscriber without blocking the caller.
+func (s *Storage) emit(ev Event) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	for _, sub := range s.subs {
+		sub.push(ev)
+	}
+}
+
+// drainIdleTimeout is how long [DrainEvents] waits for another event before
+// concluding that no more are coming.
+const drainIdleTimeout = 10 * time.Millisecond
+
+// DrainEvents blocks until at least one event arrives on events and then
+// collects every event that arrives within a short idle window, returning
+// them in order.  It's intended for use in tests that need to observe
+// [Storage] events synchronously.
+func DrainEvents(events <-chan Event) (drained []Event) {
+	drained = append(drained, <-events)
+
+	for {
+		select {
+		case ev := <-events:
+			drained = append(drained, ev)
+		case <-time.After(drainIdleTimeout):
+			return drained
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package client_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Subscribe(t *testing.T) {
+	s := client.NewStorage()
+	events := s.Subscribe()
+
+	p := &client.Persistent{
+		Name: "subscriber_test",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		UID:  client.MustNewUID(),
+	}
+
+	err := s.Add(p)
+	require.NoError(t, err)
+
+	got := client.DrainEvents(events)
+	require.Len(t, got, 1)
+	assert.Equal(t, client.EventAdded, got[0].Kind)
+	assert.Equal(t, p.Name, got[0].New.Name)
+	assert.Nil(t, got[0].Old)
+
+	ok := s.RemoveByName(p.Name)
+	require.True(t, ok)
+
+	got = client.DrainEvents(events)
+	require.Len(t, got, 1)
+	assert.Equal(t, client.EventRemoved, got[0].Kind)
+	assert.Equal(t, p.Name, got[0].Old.Name)
+	assert.Nil(t, got[0].New)
+}
+
+func TestStorage_Unsubscribe(t *testing.T) {
+	s := client.NewStorage()
+	events := s.Subscribe()
+	s.Unsubscribe(events)
+
+	err := s.Add(&client.Persistent{
+		Name: "unsubscribe_test",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	select {
+	case <-events:
+		t.Fatal("unsubscribed channel should not receive events")
+	default:
+		// No event delivered, as expected.
+	}
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// indexSnapshot is an immutable view of every persistent client, built fresh
+// by [Storage.rebuildSnapshot] after each successful write and published
+// through [Storage.snapshot].  Because a published indexSnapshot is never
+// modified, readers can load it and use it without taking any lock.
+type indexSnapshot struct {
+	// byName maps a client's name to the client itself.
+	byName map[string]*Persistent
+
+	// names holds every client's name, sorted, for [Storage.RangeByName].
+	names []string
+
+	// byID maps the string representation of a client ID, IP address, or MAC
+	// address to the client carrying it, mirroring the lookup
+	// [Storage.Find] performs.
+	byID map[string]*Persistent
+
+	// byIPNoZone maps a client's IP address, with any IPv6 zone stripped, to
+	// the client, for [Storage.FindLoose] and [Storage.FindByAddr].  As with
+	// the rest of the package, the result for an address shared by several
+	// clients with different zones is indeterminate.
+	byIPNoZone map[netip.Addr]*Persistent
+
+	// byMAC maps the string representation of a client's MAC address to the
+	// client, for [Storage.FindByMAC].
+	byMAC map[string]*Persistent
+}
+
+// newIndexSnapshot returns a new, empty indexSnapshot.
+func newIndexSnapshot() (snap *indexSnapshot) {
+	return &indexSnapshot{
+		byName:     map[string]*Persistent{},
+		byID:       map[string]*Persistent{},
+		byIPNoZone: map[netip.Addr]*Persistent{},
+		byMAC:      map[string]*Persistent{},
+	}
+}
+
+// buildIndexSnapshot returns a new indexSnapshot reflecting byUID.  The
+// caller must hold [Storage.mu] for writing, since byUID is mutated under
+// that lock.
+func buildIndexSnapshot(byUID map[UID]*Persistent) (snap *indexSnapshot) {
+	snap = newIndexSnapshot()
+	snap.names = make([]string, 0, len(byUID))
+
+	for _, p := range byUID {
+		snap.byName[p.Name] = p
+		snap.names = append(snap.names, p.Name)
+
+		for _, id := range p.ClientIDs {
+			snap.byID[id] = p
+		}
+
+		for _, ip := range p.IPs {
+			snap.byID[ip.String()] = p
+			snap.byIPNoZone[ip.WithZone("")] = p
+		}
+
+		if len(p.MAC) != 0 {
+			macID := p.MAC.String()
+			snap.byID[macID] = p
+			snap.byMAC[macID] = p
+		}
+	}
+
+	sort.Strings(snap.names)
+
+	return snap
+}
+
+// rebuildSnapshot recomputes the published indexSnapshot from s.byUID and
+// publishes it.  The caller must hold [Storage.mu] for writing and must call
+// this only after s.byUID reflects the change being made, so that readers
+// never observe a state s.byUID didn't actually pass through.
+func (s *Storage) rebuildSnapshot() {
+	s.snapshot.Store(buildIndexSnapshot(s.byUID))
+}
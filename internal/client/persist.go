@@ -0,0 +1,336 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// currentSchemaVersion is the current version of the on-disk client-storage
+// schema produced by [Storage.SaveToFile] and understood without migration
+// by [Storage.LoadFromFile].
+const currentSchemaVersion = 1
+
+// fileSchema is the on-disk representation of a [Storage].
+type fileSchema struct {
+	Clients       []*Persistent `json:"clients" yaml:"clients"`
+	SchemaVersion int           `json:"schema_version" yaml:"schema_version"`
+}
+
+// migrationFunc rolls a raw, serialized [fileSchema] document forward by one
+// schema version.
+type migrationFunc func(raw []byte) (next []byte, err error)
+
+// migrations maps a schema version to the function that upgrades a document
+// of that version to the next one.  It's populated by [RegisterMigration] as
+// the schema evolves.
+var migrations = map[int]migrationFunc{}
+
+// RegisterMigration registers mig as the migration to run on a stored
+// document whose schema_version is from, bringing it to from+1.  It's meant
+// to be called from init funcs in files introducing a schema change, such as
+// a UID field or a field rename.
+func RegisterMigration(from int, mig migrationFunc) {
+	migrations[from] = mig
+}
+
+// readSchemaFile reads and migrates the document at path up to
+// [currentSchemaVersion].  The returned error wraps [os.ErrNotExist] as-is,
+// so callers can check for it with [errors.Is].
+func readSchemaFile(path string) (schema fileSchema, err error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fileSchema{}, err
+	}
+
+	raw, err = migrate(raw)
+	if err != nil {
+		return fileSchema{}, err
+	}
+
+	if err = json.Unmarshal(raw, &schema); err != nil {
+		return fileSchema{}, fmt.Errorf("unmarshaling: %w", err)
+	}
+
+	return schema, nil
+}
+
+// LoadFromFile reads persistent clients from the file at path and adds them
+// to s.  It's meant to be called once, right after [NewStorage], since it
+// only ever adds clients and will report a clash if s already contains a
+// client with the same name, UID, IP, etc.; to pick up external changes to
+// an already-populated Storage, use [Storage.WatchFile] instead.  It's not
+// an error for path to not exist; in that case, LoadFromFile is a no-op.
+func (s *Storage) LoadFromFile(path string) (err error) {
+	defer func() { err = errors.Annotate(err, "loading clients from %q: %w", path) }()
+
+	schema, err := readSchemaFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading: %w", err)
+	}
+
+	for _, p := range schema.Clients {
+		if err = s.Add(p); err != nil {
+			return fmt.Errorf("adding %q: %w", p.Name, err)
+		}
+	}
+
+	s.setDirtySince(time.Time{})
+
+	return nil
+}
+
+// migrate rolls raw forward to currentSchemaVersion, applying registered
+// migrations one version at a time.
+func migrate(raw []byte) (next []byte, err error) {
+	var versioned struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+
+	next = raw
+	for {
+		if err = json.Unmarshal(next, &versioned); err != nil {
+			return nil, fmt.Errorf("reading schema version: %w", err)
+		}
+
+		if versioned.SchemaVersion == currentSchemaVersion {
+			return next, nil
+		}
+
+		mig, ok := migrations[versioned.SchemaVersion]
+		if !ok {
+			return nil, fmt.Errorf("no migration from schema version %d", versioned.SchemaVersion)
+		}
+
+		next, err = mig(next)
+		if err != nil {
+			return nil, fmt.Errorf("migrating from schema version %d: %w", versioned.SchemaVersion, err)
+		}
+	}
+}
+
+// SaveToFile writes every persistent client in s to the file at path as
+// JSON, replacing its previous contents atomically.
+func (s *Storage) SaveToFile(path string) (err error) {
+	defer func() { err = errors.Annotate(err, "saving clients to %q: %w", path) }()
+
+	schema := &fileSchema{
+		SchemaVersion: currentSchemaVersion,
+	}
+
+	s.RangeByName(func(c *Persistent) (cont bool) {
+		schema.Clients = append(schema.Clients, c)
+
+		return true
+	})
+
+	data, err := json.MarshalIndent(schema, "", "\t")
+	if err != nil {
+		return fmt.Errorf("marshaling: %w", err)
+	}
+
+	if err = atomicWriteFile(path, data); err != nil {
+		return err
+	}
+
+	s.setDirtySince(time.Time{})
+
+	return nil
+}
+
+// atomicWriteFile writes data to path by writing it to a temporary file in
+// the same directory, syncing it, and renaming it over path, so that readers
+// never observe a partially written file.
+func atomicWriteFile(path string, data []byte) (err error) {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return fmt.Errorf("writing: %w", err)
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("syncing: %w", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("renaming: %w", err)
+	}
+
+	return nil
+}
+
+// DirtySince returns the time s started diverging from the file it was last
+// loaded from or saved to, so that a caller can coalesce frequent mutations
+// into a single save.  It returns the zero [time.Time] if s has no unsaved
+// changes.
+func (s *Storage) DirtySince() (t time.Time) {
+	s.dirtyMu.Lock()
+	defer s.dirtyMu.Unlock()
+
+	return s.dirtySince
+}
+
+// setDirtySince overwrites the dirty-since timestamp, e.g. to clear it after
+// a successful load or save.
+func (s *Storage) setDirtySince(t time.Time) {
+	s.dirtyMu.Lock()
+	defer s.dirtyMu.Unlock()
+
+	s.dirtySince = t
+}
+
+// markDirty records that s has changed, unless it is already marked dirty.
+func (s *Storage) markDirty() {
+	s.dirtyMu.Lock()
+	defer s.dirtyMu.Unlock()
+
+	if s.dirtySince.IsZero() {
+		s.dirtySince = time.Now()
+	}
+}
+
+// watchFilePollInterval is how often [Storage.WatchFile] checks path for
+// external changes.  It's a variable rather than a constant so that tests
+// can shorten it.
+var watchFilePollInterval = 2 * time.Second
+
+// WatchFile starts watching path for external changes and reloads s from it
+// whenever its modification time advances, unless s has unsaved in-memory
+// changes, in which case the reload is skipped and logged to avoid
+// clobbering them.  The returned stop func stops the watch.
+func (s *Storage) WatchFile(path string) (stop func(), err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("watching %q: %w", path, err)
+	}
+
+	lastMod := fi.ModTime()
+	done := make(chan struct{})
+
+	go func() {
+		t := time.NewTicker(watchFilePollInterval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				s.reloadIfChanged(path, &lastMod)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return sync.OnceFunc(func() { close(done) }), nil
+}
+
+// reloadIfChanged replaces s's clients with the contents of path if the
+// file's modification time has advanced past *lastMod, updating *lastMod in
+// the process.
+func (s *Storage) reloadIfChanged(path string, lastMod *time.Time) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		log.Error("client storage: watching %q: %s", path, err)
+
+		return
+	}
+
+	if !fi.ModTime().After(*lastMod) {
+		return
+	}
+
+	*lastMod = fi.ModTime()
+
+	if dirty := s.DirtySince(); !dirty.IsZero() {
+		log.Info("client storage: %q changed on disk, skipping reload due to unsaved changes since %s", path, dirty)
+
+		return
+	}
+
+	if err = s.replaceFromFile(path); err != nil {
+		log.Error("client storage: reloading %q: %s", path, err)
+	}
+}
+
+// replaceFromFile reads the clients stored at path and reconciles s's
+// clients with them in place: clients absent from the file are removed,
+// clients present in both are replaced with the on-disk version, and new
+// ones are added, each emitting the matching [Event].  Unlike
+// [Storage.LoadFromFile], it's safe to call on a Storage that already has
+// clients in it, which is why [Storage.WatchFile] uses it for reloads.
+func (s *Storage) replaceFromFile(path string) (err error) {
+	defer func() { err = errors.Annotate(err, "replacing clients from %q: %w", path) }()
+
+	schema, err := readSchemaFile(path)
+	if err != nil {
+		return fmt.Errorf("reading: %w", err)
+	}
+
+	next := make(map[UID]*Persistent, len(schema.Clients))
+	for _, p := range schema.Clients {
+		next[p.UID] = p
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for uid, old := range s.byUID {
+		if _, ok := next[uid]; ok {
+			continue
+		}
+
+		if closeErr := old.CloseUpstreams(); closeErr != nil {
+			log.Error("client storage: replacing clients: closing upstreams for %q: %s", old.Name, closeErr)
+		}
+
+		s.index.Delete(old)
+		delete(s.byUID, uid)
+		s.removeFromTagIndex(old)
+		s.emit(Event{Kind: EventRemoved, Old: old})
+	}
+
+	for uid, n := range next {
+		old, exists := s.byUID[uid]
+		if exists {
+			s.index.Delete(old)
+			s.removeFromTagIndex(old)
+		}
+
+		s.index.Add(n)
+		s.byUID[uid] = n
+		s.addToTagIndex(n)
+
+		if exists {
+			s.emit(Event{Kind: EventUpdated, Old: old, New: n})
+		} else {
+			s.emit(Event{Kind: EventAdded, New: n})
+		}
+	}
+
+	s.rebuildSnapshot()
+	s.setDirtySince(time.Time{})
+
+	return nil
+}
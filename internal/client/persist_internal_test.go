@@ -0,0 +1,208 @@
+package client
+
+import (
+	"encoding/json"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSchemaFile writes clients to path using the on-disk schema, without
+// going through a Storage, to simulate an external edit.
+func writeSchemaFile(t *testing.T, path string, clients []*Persistent) {
+	t.Helper()
+
+	data, err := json.MarshalIndent(fileSchema{
+		SchemaVersion: currentSchemaVersion,
+		Clients:       clients,
+	}, "", "\t")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+}
+
+// TestStorage_replaceFromFile exercises a reload of an already-populated
+// Storage, which is what LoadFromFile itself can't do (see its doc comment).
+func TestStorage_replaceFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.json")
+
+	kept := &Persistent{
+		Name: "kept",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.1.1.1")},
+		UID:  MustNewUID(),
+	}
+	removed := &Persistent{
+		Name: "removed",
+		IPs:  []netip.Addr{netip.MustParseAddr("2.2.2.2")},
+		UID:  MustNewUID(),
+	}
+
+	s := NewStorage()
+	require.NoError(t, s.Add(kept))
+	require.NoError(t, s.Add(removed))
+
+	events := s.Subscribe()
+
+	updatedKept := &Persistent{
+		Name: "kept",
+		IPs:  []netip.Addr{netip.MustParseAddr("3.3.3.3")},
+		UID:  kept.UID,
+	}
+	added := &Persistent{
+		Name: "added",
+		IPs:  []netip.Addr{netip.MustParseAddr("4.4.4.4")},
+		UID:  MustNewUID(),
+	}
+
+	writeSchemaFile(t, path, []*Persistent{updatedKept, added})
+
+	err := s.replaceFromFile(path)
+	require.NoError(t, err)
+
+	_, ok := s.FindByName("removed")
+	assert.False(t, ok, "client absent from the file should be removed")
+
+	p, ok := s.FindByName("kept")
+	require.True(t, ok)
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("3.3.3.3")}, p.IPs)
+
+	_, ok = s.FindByName("added")
+	assert.True(t, ok, "client new to the file should be added")
+
+	got := DrainEvents(events)
+	require.Len(t, got, 3)
+
+	kinds := make(map[EventKind]int, 3)
+	for _, ev := range got {
+		kinds[ev.Kind]++
+	}
+	assert.Equal(t, 1, kinds[EventRemoved])
+	assert.Equal(t, 1, kinds[EventUpdated])
+	assert.Equal(t, 1, kinds[EventAdded])
+
+	assert.True(t, s.DirtySince().IsZero())
+}
+
+// TestMigrate exercises RegisterMigration and migrate rolling a document
+// forward from an older schema version to the current one, as well as the
+// error path for a version with no registered migration.
+func TestMigrate(t *testing.T) {
+	origMigrations := migrations
+	migrations = map[int]migrationFunc{}
+	t.Cleanup(func() { migrations = origMigrations })
+
+	RegisterMigration(0, func(raw []byte) (next []byte, err error) {
+		var doc map[string]any
+		if err = json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+
+		doc["schema_version"] = 1
+		doc["migrated_from_0"] = true
+
+		return json.Marshal(doc)
+	})
+
+	got, err := migrate([]byte(`{"schema_version":0,"clients":[]}`))
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(got, &doc))
+	assert.Equal(t, float64(1), doc["schema_version"])
+	assert.Equal(t, true, doc["migrated_from_0"])
+
+	_, err = migrate([]byte(`{"schema_version":2,"clients":[]}`))
+	assert.ErrorContains(t, err, "no migration from schema version 2")
+}
+
+// TestStorage_LoadFromFile_migrates exercises readSchemaFile, and therefore
+// LoadFromFile, applying a registered migration to an old-version document
+// before loading it.
+func TestStorage_LoadFromFile_migrates(t *testing.T) {
+	origMigrations := migrations
+	migrations = map[int]migrationFunc{}
+	t.Cleanup(func() { migrations = origMigrations })
+
+	RegisterMigration(0, func(raw []byte) (next []byte, err error) {
+		var doc map[string]any
+		if err = json.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+
+		doc["schema_version"] = currentSchemaVersion
+
+		return json.Marshal(doc)
+	})
+
+	path := filepath.Join(t.TempDir(), "clients.json")
+	data, err := json.Marshal(fileSchema{
+		SchemaVersion: currentSchemaVersion,
+		Clients: []*Persistent{{
+			Name: "migrated",
+			IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+			UID:  MustNewUID(),
+		}},
+	})
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(data, &doc))
+	doc["schema_version"] = 0
+	data, err = json.Marshal(doc)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	s := NewStorage()
+	require.NoError(t, s.LoadFromFile(path))
+
+	p, ok := s.FindByName("migrated")
+	require.True(t, ok)
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("1.2.3.4")}, p.IPs)
+}
+
+// TestStorage_WatchFile_reload exercises the full poll-and-reload path with
+// a shortened poll interval.
+func TestStorage_WatchFile_reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.json")
+
+	existing := &Persistent{
+		Name: "existing",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		UID:  MustNewUID(),
+	}
+
+	s := NewStorage()
+	require.NoError(t, s.Add(existing))
+	require.NoError(t, s.SaveToFile(path))
+
+	oldInterval := watchFilePollInterval
+	watchFilePollInterval = 5 * time.Millisecond
+	defer func() { watchFilePollInterval = oldInterval }()
+
+	stop, err := s.WatchFile(path)
+	require.NoError(t, err)
+	defer stop()
+
+	// Let some time pass so that the rewritten file's modification time is
+	// later than the baseline WatchFile recorded when it was started.
+	time.Sleep(20 * time.Millisecond)
+
+	updated := &Persistent{
+		Name: "existing",
+		IPs:  []netip.Addr{netip.MustParseAddr("4.3.2.1")},
+		UID:  existing.UID,
+	}
+	writeSchemaFile(t, path, []*Persistent{updated})
+
+	require.Eventually(t, func() bool {
+		p, ok := s.FindByName("existing")
+
+		return ok && len(p.IPs) == 1 && p.IPs[0] == netip.MustParseAddr("4.3.2.1")
+	}, time.Second, 5*time.Millisecond, "reload never picked up the on-disk change")
+}
@@ -0,0 +1,59 @@
+package client_test
+
+import (
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_SaveLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.json")
+
+	s := client.NewStorage()
+	err := s.Add(&client.Persistent{
+		Name: "existing_name",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	err = s.SaveToFile(path)
+	require.NoError(t, err)
+	assert.True(t, s.DirtySince().IsZero())
+
+	loaded := client.NewStorage()
+	err = loaded.LoadFromFile(path)
+	require.NoError(t, err)
+
+	p, ok := loaded.FindByName("existing_name")
+	require.True(t, ok)
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("1.2.3.4")}, p.IPs)
+}
+
+func TestStorage_LoadFromFile_notExist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.json")
+
+	s := client.NewStorage()
+	err := s.LoadFromFile(path)
+	require.NoError(t, err)
+
+	assert.Zero(t, s.Size())
+}
+
+func TestStorage_DirtySince(t *testing.T) {
+	s := client.NewStorage()
+	assert.True(t, s.DirtySince().IsZero())
+
+	err := s.Add(&client.Persistent{
+		Name: "dirty_test",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	assert.False(t, s.DirtySince().IsZero())
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"net/netip"
+	"sync"
+)
+
+// Source is the kind of discovery mechanism that supplied information about
+// a runtime client.
+type Source int
+
+// Runtime-client sources, listed in order of increasing priority.  A record
+// discovered through a source further down this list supersedes a record
+// discovered through a source above it, for the same IP address.
+const (
+	SourceWHOIS Source = iota + 1
+	SourceRDNS
+	SourceARP
+	SourceDHCP
+	SourceHostsFile
+)
+
+// RuntimeClient is information about a client discovered via a [Source],
+// such as a DHCP lease, the ARP table, /etc/hosts, or reverse DNS, as opposed
+// to a [Persistent] client configured explicitly by the user.
+type RuntimeClient struct {
+	// Host is the hostname of the client, as reported by Source.  It may be
+	// empty.
+	Host string
+
+	// Source is the source that supplied this record.
+	Source Source
+}
+
+// runtimeIndex stores runtime clients indexed by IP address.  It keeps every
+// source's record around, so that clearing one source doesn't lose a record
+// from a lower-priority source that is still valid.
+type runtimeIndex struct {
+	mu *sync.Mutex
+
+	// clients maps an IP address to its known runtime records, keyed by
+	// source.
+	clients map[netip.Addr]map[Source]*RuntimeClient
+}
+
+// newRuntimeIndex returns a new, empty runtimeIndex.
+func newRuntimeIndex() (idx *runtimeIndex) {
+	return &runtimeIndex{
+		mu:      &sync.Mutex{},
+		clients: map[netip.Addr]map[Source]*RuntimeClient{},
+	}
+}
+
+// add stores rc as the record from src for ip, replacing any previous record
+// from the same source for that address.
+func (idx *runtimeIndex) add(ip netip.Addr, src Source, rc *RuntimeClient) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	byIP, ok := idx.clients[ip]
+	if !ok {
+		byIP = map[Source]*RuntimeClient{}
+		idx.clients[ip] = byIP
+	}
+
+	byIP[src] = rc
+}
+
+// find returns the highest-priority runtime record known for ip.
+func (idx *runtimeIndex) find(ip netip.Addr) (rc *RuntimeClient, ok bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	byIP, ok := idx.clients[ip]
+	if !ok {
+		return nil, false
+	}
+
+	var best *RuntimeClient
+	for src, c := range byIP {
+		if best == nil || src > best.Source {
+			best = c
+		}
+	}
+
+	return best, best != nil
+}
+
+// clearSource removes every record that came from src, dropping IP entries
+// that end up with no remaining record.
+func (idx *runtimeIndex) clearSource(src Source) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for ip, byIP := range idx.clients {
+		delete(byIP, src)
+		if len(byIP) == 0 {
+			delete(idx.clients, ip)
+		}
+	}
+}
+
+// AddRuntime stores information about a runtime client discovered via src at
+// ip, superseding any previous record from the same source for that address.
+func (s *Storage) AddRuntime(ip netip.Addr, src Source, rc *RuntimeClient) {
+	s.runtime.add(ip, src, rc)
+}
+
+// FindRuntime returns the highest-priority runtime client known for ip.
+func (s *Storage) FindRuntime(ip netip.Addr) (rc *RuntimeClient, ok bool) {
+	return s.runtime.find(ip)
+}
+
+// ClearSource removes every runtime record that came from src.  It's used
+// when a discovery source, such as the DHCP server, is about to refresh its
+// data wholesale and stale records it no longer reports shouldn't linger.
+func (s *Storage) ClearSource(src Source) {
+	s.runtime.clearSource(src)
+}
+
+// FindByAddr looks up the client with the given IP address, preferring a
+// persistent client over a runtime one.  p is nil unless a persistent client
+// is found; rc is nil unless a runtime client is found instead.  Like
+// [Storage.FindByName], the persistent lookup takes no lock.
+func (s *Storage) FindByAddr(ip netip.Addr) (p *Persistent, rc *RuntimeClient, found bool) {
+	p, found = s.snapshot.Load().byIPNoZone[ip]
+	if found {
+		return p.ShallowClone(), nil, true
+	}
+
+	rc, found = s.FindRuntime(ip)
+
+	return nil, rc, found
+}
@@ -0,0 +1,87 @@
+package client_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Runtime(t *testing.T) {
+	ip := netip.MustParseAddr("1.2.3.4")
+
+	s := client.NewStorage()
+
+	_, ok := s.FindRuntime(ip)
+	assert.False(t, ok)
+
+	s.AddRuntime(ip, client.SourceRDNS, &client.RuntimeClient{
+		Host:   "rdns.example",
+		Source: client.SourceRDNS,
+	})
+
+	rc, ok := s.FindRuntime(ip)
+	require.True(t, ok)
+	assert.Equal(t, "rdns.example", rc.Host)
+
+	s.AddRuntime(ip, client.SourceDHCP, &client.RuntimeClient{
+		Host:   "dhcp.example",
+		Source: client.SourceDHCP,
+	})
+
+	rc, ok = s.FindRuntime(ip)
+	require.True(t, ok)
+	assert.Equal(t, "dhcp.example", rc.Host, "higher-priority source should win")
+
+	s.ClearSource(client.SourceDHCP)
+
+	rc, ok = s.FindRuntime(ip)
+	require.True(t, ok)
+	assert.Equal(t, "rdns.example", rc.Host, "lower-priority source should resurface")
+
+	s.ClearSource(client.SourceRDNS)
+
+	_, ok = s.FindRuntime(ip)
+	assert.False(t, ok)
+}
+
+func TestStorage_FindByAddr(t *testing.T) {
+	persistentIP := netip.MustParseAddr("1.2.3.4")
+	runtimeIP := netip.MustParseAddr("4.3.2.1")
+
+	s := client.NewStorage()
+	err := s.Add(&client.Persistent{
+		Name: "persistent_client",
+		IPs:  []netip.Addr{persistentIP},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	s.AddRuntime(runtimeIP, client.SourceDHCP, &client.RuntimeClient{
+		Host:   "dhcp.example",
+		Source: client.SourceDHCP,
+	})
+
+	t.Run("persistent", func(t *testing.T) {
+		p, rc, found := s.FindByAddr(persistentIP)
+		require.True(t, found)
+
+		assert.NotNil(t, p)
+		assert.Nil(t, rc)
+	})
+
+	t.Run("runtime", func(t *testing.T) {
+		p, rc, found := s.FindByAddr(runtimeIP)
+		require.True(t, found)
+
+		assert.Nil(t, p)
+		assert.NotNil(t, rc)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		_, _, found := s.FindByAddr(netip.MustParseAddr("8.8.8.8"))
+		assert.False(t, found)
+	})
+}
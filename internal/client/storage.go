@@ -5,6 +5,8 @@ import (
 	"net"
 	"net/netip"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
@@ -12,19 +14,68 @@ import (
 
 // Storage contains information about persistent and runtime clients.
 type Storage struct {
-	// mu protects index of persistent clients.
-	mu *sync.Mutex
-
-	// index contains information about persistent clients.
+	// mu serializes writers (producers building the next snapshot) and
+	// guards index, byUID, and tagIndex.  Lookups by name, ID, IP, or MAC
+	// don't take it; those load snapshot instead.  The tag queries, such as
+	// [Storage.FindByTag], take it for reading.  Clients are
+	// only added, updated, or removed through the UI, API, or a file
+	// reload, so contention on mu is rare even though snapshot is read on
+	// every DNS query.
+	mu *sync.RWMutex
+
+	// index contains information about persistent clients, and is used for
+	// clash detection and closing upstreams.  It is never read from
+	// directly to answer a lookup; see snapshot for that.
 	index *Index
+
+	// snapshot holds the current, immutable [indexSnapshot] of every
+	// persistent client.  Writers build a new one under mu and publish it
+	// with [Storage.rebuildSnapshot]; readers load it without taking mu at
+	// all.
+	snapshot atomic.Pointer[indexSnapshot]
+
+	// runtime contains information about runtime clients discovered via
+	// DHCP, ARP, /etc/hosts, rDNS, and WHOIS.
+	runtime *runtimeIndex
+
+	// subsMu protects subs.
+	subsMu *sync.Mutex
+
+	// subs contains the current subscribers to persistent-client change
+	// events, keyed by the channel returned to them from [Storage.Subscribe].
+	subs map[chan Event]*eventSub
+
+	// dirtyMu protects dirtySince.
+	dirtyMu *sync.Mutex
+
+	// dirtySince is the time at which s started diverging from the file it
+	// was last loaded from or saved to.  It's the zero [time.Time] if there
+	// are no unsaved changes.
+	dirtySince time.Time
+
+	// byUID maps a client's UID to the client itself, so that tagIndex can
+	// be resolved to clients without scanning index.
+	byUID map[UID]*Persistent
+
+	// tagIndex maps a tag to the UIDs of the persistent clients carrying it.
+	tagIndex map[string]map[UID]struct{}
 }
 
 // NewStorage returns initialized client storage.
 func NewStorage() (s *Storage) {
-	return &Storage{
-		mu:    &sync.Mutex{},
-		index: NewIndex(),
+	s = &Storage{
+		mu:       &sync.RWMutex{},
+		index:    NewIndex(),
+		runtime:  newRuntimeIndex(),
+		subsMu:   &sync.Mutex{},
+		subs:     map[chan Event]*eventSub{},
+		dirtyMu:  &sync.Mutex{},
+		byUID:    map[UID]*Persistent{},
+		tagIndex: map[string]map[UID]struct{}{},
 	}
+	s.snapshot.Store(newIndexSnapshot())
+
+	return s
 }
 
 // Add stores persistent client information or returns an error.  p must be
@@ -48,34 +99,53 @@ func (s *Storage) Add(p *Persistent) (err error) {
 	}
 
 	s.index.Add(p)
+	s.byUID[p.UID] = p
+	s.addToTagIndex(p)
+	s.rebuildSnapshot()
+	s.emit(Event{Kind: EventAdded, New: p})
+	s.markDirty()
 
 	log.Debug("client storage: added %q: IDs: %q [%d]", p.Name, p.IDs(), s.index.Size())
 
 	return nil
 }
 
-// FindByName finds persistent client by name.
+// FindByName finds persistent client by name.  It takes no lock: it loads
+// the current [indexSnapshot] and reads from it, which is safe because a
+// published snapshot is never modified.
 func (s *Storage) FindByName(name string) (c *Persistent, found bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	c, found = s.snapshot.Load().byName[name]
 
-	return s.index.FindByName(name)
+	return c, found
 }
 
 // Find finds persistent client by string representation of the client ID, IP
-// address, or MAC.  And returns it shallow copy.
+// address, or MAC, and returns its shallow copy.  Like [Storage.FindByName],
+// it takes no lock.
 func (s *Storage) Find(id string) (p *Persistent, ok bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	p, ok = s.index.Find(id)
+	p, ok = s.snapshot.Load().byID[id]
 	if ok {
-		return p.ShallowClone(), ok
+		return p.ShallowClone(), true
 	}
 
 	return nil, false
 }
 
+// FindRef is like [Storage.Find], but it skips the clone and returns the
+// stored *Persistent directly, which is cheaper when the caller only reads
+// the client.  Storage never mutates a stored *Persistent in place — writes
+// always replace it with a new value (see [Storage.Update], [Storage.UpdateTag],
+// [Storage.RemoveTag]) — and the [indexSnapshot] FindRef reads from is itself
+// immutable once published, so the returned *Persistent is guaranteed not to
+// be mutated.  It can, however, fall out of date: a later write publishes a
+// new snapshot that may no longer contain it, so don't use FindRef's result
+// to infer whether the client is still present in Storage.
+func (s *Storage) FindRef(id string) (p *Persistent, ok bool) {
+	p, ok = s.snapshot.Load().byID[id]
+
+	return p, ok
+}
+
 // FindLoose is like [Storage.Find] but it also tries to find a persistent
 // client by IP address without zone.  It strips the IPv6 zone index from the
 // stored IP addresses before comparing, because querylog entries don't have it.
@@ -84,16 +154,15 @@ func (s *Storage) Find(id string) (p *Persistent, ok bool) {
 // Note that multiple clients can have the same IP address with different zones.
 // Therefore, the result of this method is indeterminate.
 func (s *Storage) FindLoose(ip netip.Addr, id string) (p *Persistent, ok bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	snap := s.snapshot.Load()
 
-	p, ok = s.index.Find(id)
+	p, ok = snap.byID[id]
 	if ok {
-		return p.ShallowClone(), ok
+		return p.ShallowClone(), true
 	}
 
-	p = s.index.FindByIPWithoutZone(ip)
-	if p != nil {
+	p, ok = snap.byIPNoZone[ip]
+	if ok {
 		return p.ShallowClone(), true
 	}
 
@@ -102,10 +171,9 @@ func (s *Storage) FindLoose(ip netip.Addr, id string) (p *Persistent, ok bool) {
 
 // FindByMAC finds persistent client by MAC.
 func (s *Storage) FindByMAC(mac net.HardwareAddr) (c *Persistent, found bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	c, found = s.snapshot.Load().byMAC[mac.String()]
 
-	return s.index.FindByMAC(mac)
+	return c, found
 }
 
 // RemoveByName removes persistent client information.  ok is false if no such
@@ -124,6 +192,11 @@ func (s *Storage) RemoveByName(name string) (ok bool) {
 	}
 
 	s.index.Delete(p)
+	delete(s.byUID, p.UID)
+	s.removeFromTagIndex(p)
+	s.rebuildSnapshot()
+	s.emit(Event{Kind: EventRemoved, Old: p})
+	s.markDirty()
 
 	return true
 }
@@ -155,25 +228,31 @@ func (s *Storage) Update(name string, n *Persistent) (err error) {
 
 	s.index.Delete(stored)
 	s.index.Add(n)
+	s.removeFromTagIndex(stored)
+	s.byUID[n.UID] = n
+	s.addToTagIndex(n)
+	s.rebuildSnapshot()
+	s.emit(Event{Kind: EventUpdated, Old: stored, New: n})
+	s.markDirty()
 
 	return nil
 }
 
-// RangeByName calls f for each persistent client sorted by name, unless cont is
-// false.
+// RangeByName calls f for each persistent client sorted by name, unless cont
+// is false.  It takes no lock; see [Storage.FindByName].
 func (s *Storage) RangeByName(f func(c *Persistent) (cont bool)) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.index.RangeByName(f)
+	snap := s.snapshot.Load()
+	for _, name := range snap.names {
+		if !f(snap.byName[name]) {
+			return
+		}
+	}
 }
 
-// Size returns the number of persistent clients.
+// Size returns the number of persistent clients.  It takes no lock; see
+// [Storage.FindByName].
 func (s *Storage) Size() (n int) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	return s.index.Size()
+	return len(s.snapshot.Load().names)
 }
 
 // CloseUpstreams closes upstream configurations of persistent clients.
@@ -0,0 +1,42 @@
+package client_test
+
+import (
+	"net/netip"
+	"strconv"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/client"
+	"github.com/stretchr/testify/require"
+)
+
+// BenchmarkStorage_Find_Parallel demonstrates that concurrent reads scale,
+// since Find loads an immutable snapshot without taking any lock instead of
+// serializing on a single mutex.
+func BenchmarkStorage_Find_Parallel(b *testing.B) {
+	const numClients = 10_000
+
+	ips := make([]netip.Addr, numClients)
+
+	s := client.NewStorage()
+	for i := range numClients {
+		ips[i] = netip.AddrFrom4([4]byte{10, 0, byte(i >> 8), byte(i)})
+
+		err := s.Add(&client.Persistent{
+			Name: "client_" + strconv.Itoa(i),
+			IPs:  []netip.Addr{ips[i]},
+			UID:  client.MustNewUID(),
+		})
+		require.NoError(b, err)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_, _ = s.Find(ips[i%numClients].String())
+			i++
+		}
+	})
+}
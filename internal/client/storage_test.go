@@ -143,3 +143,25 @@ func TestStorage_RemoveByName(t *testing.T) {
 		assert.False(t, s.RemoveByName(existingName))
 	})
 }
+
+func TestStorage_FindRef(t *testing.T) {
+	const existingClientID = "existing_client_id"
+
+	existingClient := &client.Persistent{
+		Name:      "existing_name",
+		ClientIDs: []string{existingClientID},
+		UID:       client.MustNewUID(),
+	}
+
+	s := client.NewStorage()
+	err := s.Add(existingClient)
+	require.NoError(t, err)
+
+	p, ok := s.FindRef(existingClientID)
+	require.True(t, ok)
+
+	assert.Same(t, existingClient, p)
+
+	_, ok = s.FindRef("non_existing_client_id")
+	assert.False(t, ok)
+}
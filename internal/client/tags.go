@@ -0,0 +1,181 @@
+package client
+
+// addToTagIndex records p's tags in s.tagIndex.  It must be called with s.mu
+// held for writing.
+func (s *Storage) addToTagIndex(p *Persistent) {
+	for _, tag := range p.Tags {
+		uids, ok := s.tagIndex[tag]
+		if !ok {
+			uids = map[UID]struct{}{}
+			s.tagIndex[tag] = uids
+		}
+
+		uids[p.UID] = struct{}{}
+	}
+}
+
+// removeFromTagIndex removes p's tags from s.tagIndex, dropping tags left
+// with no clients.  It must be called with s.mu held for writing.
+func (s *Storage) removeFromTagIndex(p *Persistent) {
+	for _, tag := range p.Tags {
+		uids, ok := s.tagIndex[tag]
+		if !ok {
+			continue
+		}
+
+		delete(uids, p.UID)
+		if len(uids) == 0 {
+			delete(s.tagIndex, tag)
+		}
+	}
+}
+
+// FindByTag returns every persistent client tagged with tag, in unspecified
+// order.
+func (s *Storage) FindByTag(tag string) (clients []*Persistent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	uids, ok := s.tagIndex[tag]
+	if !ok {
+		return nil
+	}
+
+	clients = make([]*Persistent, 0, len(uids))
+	for uid := range uids {
+		if p, found := s.byUID[uid]; found {
+			clients = append(clients, p.ShallowClone())
+		}
+	}
+
+	return clients
+}
+
+// Tags returns the set of tags currently assigned to at least one persistent
+// client, in unspecified order.
+func (s *Storage) Tags() (tags []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tags = make([]string, 0, len(s.tagIndex))
+	for tag := range s.tagIndex {
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// RangeByTag calls f for every persistent client tagged with tag, until f
+// returns false or every matching client has been visited.
+func (s *Storage) RangeByTag(tag string, f func(c *Persistent) (cont bool)) {
+	for _, c := range s.FindByTag(tag) {
+		if !f(c) {
+			return
+		}
+	}
+}
+
+// UpdateTag renames oldTag to newTag on every persistent client that carries
+// it, atomically with respect to other Storage operations.  Like
+// [Storage.Update], it never mutates a stored *Persistent in place: each
+// affected client is replaced with a modified copy, so that a *Persistent
+// returned earlier by [Storage.Find], [Storage.FindRef], or an [Event] stays
+// unchanged for as long as anyone still holds it.
+func (s *Storage) UpdateTag(oldTag, newTag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uids, ok := s.tagIndex[oldTag]
+	if !ok {
+		return
+	}
+
+	targets := make([]UID, 0, len(uids))
+	for uid := range uids {
+		targets = append(targets, uid)
+	}
+
+	for _, uid := range targets {
+		stored, found := s.byUID[uid]
+		if !found {
+			continue
+		}
+
+		n := stored.ShallowClone()
+		n.Tags = renameTag(n.Tags, oldTag, newTag)
+
+		s.index.Delete(stored)
+		s.index.Add(n)
+		s.byUID[uid] = n
+		s.removeFromTagIndex(stored)
+		s.addToTagIndex(n)
+		s.emit(Event{Kind: EventUpdated, Old: stored, New: n})
+	}
+
+	s.rebuildSnapshot()
+	s.markDirty()
+}
+
+// RemoveTag removes tag from every persistent client that carries it, the
+// same way [Storage.UpdateTag] does: by replacing each affected client with
+// a modified copy rather than mutating the stored one in place.
+func (s *Storage) RemoveTag(tag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uids, ok := s.tagIndex[tag]
+	if !ok {
+		return
+	}
+
+	targets := make([]UID, 0, len(uids))
+	for uid := range uids {
+		targets = append(targets, uid)
+	}
+
+	for _, uid := range targets {
+		stored, found := s.byUID[uid]
+		if !found {
+			continue
+		}
+
+		n := stored.ShallowClone()
+		n.Tags = removeTag(n.Tags, tag)
+
+		s.index.Delete(stored)
+		s.index.Add(n)
+		s.byUID[uid] = n
+		s.removeFromTagIndex(stored)
+		s.addToTagIndex(n)
+		s.emit(Event{Kind: EventUpdated, Old: stored, New: n})
+	}
+
+	s.rebuildSnapshot()
+	s.markDirty()
+}
+
+// renameTag returns a copy of tags with every occurrence of oldTag replaced
+// by newTag.
+func renameTag(tags []string, oldTag, newTag string) (result []string) {
+	result = make([]string, len(tags))
+	for i, t := range tags {
+		if t == oldTag {
+			t = newTag
+		}
+
+		result[i] = t
+	}
+
+	return result
+}
+
+// removeTag returns a copy of tags with every occurrence of tag removed.
+func removeTag(tags []string, tag string) (result []string) {
+	for _, t := range tags {
+		if t != tag {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,185 @@
+package client_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Tags(t *testing.T) {
+	s := client.NewStorage()
+
+	err := s.Add(&client.Persistent{
+		Name: "kids_client",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		Tags: []string{"kids", "home"},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	err = s.Add(&client.Persistent{
+		Name: "other_client",
+		IPs:  []netip.Addr{netip.MustParseAddr("4.3.2.1")},
+		Tags: []string{"home"},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"kids", "home"}, s.Tags())
+
+	kids := s.FindByTag("kids")
+	require.Len(t, kids, 1)
+	assert.Equal(t, "kids_client", kids[0].Name)
+
+	home := s.FindByTag("home")
+	assert.Len(t, home, 2)
+
+	assert.Empty(t, s.FindByTag("non_existing_tag"))
+}
+
+func TestStorage_RangeByTag(t *testing.T) {
+	s := client.NewStorage()
+
+	err := s.Add(&client.Persistent{
+		Name: "kids_client",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		Tags: []string{"kids"},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	var names []string
+	s.RangeByTag("kids", func(c *client.Persistent) (cont bool) {
+		names = append(names, c.Name)
+
+		return true
+	})
+
+	assert.Equal(t, []string{"kids_client"}, names)
+}
+
+func TestStorage_UpdateTag(t *testing.T) {
+	s := client.NewStorage()
+
+	err := s.Add(&client.Persistent{
+		Name: "kids_client",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		Tags: []string{"kids"},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	s.UpdateTag("kids", "teens")
+
+	assert.Empty(t, s.FindByTag("kids"))
+	assert.Len(t, s.FindByTag("teens"), 1)
+}
+
+func TestStorage_RemoveTag(t *testing.T) {
+	s := client.NewStorage()
+
+	err := s.Add(&client.Persistent{
+		Name: "kids_client",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		Tags: []string{"kids"},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	s.RemoveTag("kids")
+
+	assert.Empty(t, s.FindByTag("kids"))
+	assert.Empty(t, s.Tags())
+
+	p, ok := s.FindByName("kids_client")
+	require.True(t, ok)
+	assert.NotContains(t, p.Tags, "kids")
+}
+
+// TestStorage_UpdateTag_copyOnWrite asserts that a *Persistent obtained
+// before an UpdateTag call keeps its original Tags afterward, i.e. that
+// UpdateTag replaces the stored client instead of mutating it in place.
+func TestStorage_UpdateTag_copyOnWrite(t *testing.T) {
+	s := client.NewStorage()
+
+	err := s.Add(&client.Persistent{
+		Name: "kids_client",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		Tags: []string{"kids"},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	before, ok := s.FindRef("1.2.3.4")
+	require.True(t, ok)
+
+	s.UpdateTag("kids", "teens")
+
+	assert.Equal(t, []string{"kids"}, before.Tags)
+
+	after, ok := s.FindByName("kids_client")
+	require.True(t, ok)
+	assert.Equal(t, []string{"teens"}, after.Tags)
+}
+
+// TestStorage_RemoveTag_copyOnWrite is like
+// TestStorage_UpdateTag_copyOnWrite, but for RemoveTag.
+func TestStorage_RemoveTag_copyOnWrite(t *testing.T) {
+	s := client.NewStorage()
+
+	err := s.Add(&client.Persistent{
+		Name: "kids_client",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		Tags: []string{"kids", "home"},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	before, ok := s.FindRef("1.2.3.4")
+	require.True(t, ok)
+
+	s.RemoveTag("kids")
+
+	assert.Equal(t, []string{"kids", "home"}, before.Tags)
+
+	after, ok := s.FindByName("kids_client")
+	require.True(t, ok)
+	assert.Equal(t, []string{"home"}, after.Tags)
+}
+
+// TestStorage_UpdateTag_merge asserts that renaming a tag onto one that
+// already has members merges both clients under the destination tag,
+// instead of the rename overwriting the existing membership.
+func TestStorage_UpdateTag_merge(t *testing.T) {
+	s := client.NewStorage()
+
+	err := s.Add(&client.Persistent{
+		Name: "kids_client",
+		IPs:  []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		Tags: []string{"kids"},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	err = s.Add(&client.Persistent{
+		Name: "teens_client",
+		IPs:  []netip.Addr{netip.MustParseAddr("4.3.2.1")},
+		Tags: []string{"teens"},
+		UID:  client.MustNewUID(),
+	})
+	require.NoError(t, err)
+
+	s.UpdateTag("kids", "teens")
+
+	teens := s.FindByTag("teens")
+	names := make([]string, 0, len(teens))
+	for _, c := range teens {
+		names = append(names, c.Name)
+	}
+
+	assert.ElementsMatch(t, []string{"kids_client", "teens_client"}, names)
+	assert.Empty(t, s.FindByTag("kids"))
+}